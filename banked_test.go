@@ -0,0 +1,34 @@
+package go6502
+
+import "testing"
+
+func TestRomBanksFromFileRejectsNonPositiveBankSize(t *testing.T) {
+	path := writeTempRom(t, make([]byte, 0x100))
+	if _, err := RomBanksFromFile(path, 0); err == nil {
+		t.Fatalf("expected error for bank size 0, got nil")
+	}
+	if _, err := RomBanksFromFile(path, -1); err == nil {
+		t.Fatalf("expected error for negative bank size, got nil")
+	}
+}
+
+func TestBankedMemorySwitch(t *testing.T) {
+	path := writeTempRom(t, []byte{0x00, 0x01, 0x10, 0x11})
+	banks, err := RomBanksFromFile(path, 2)
+	if err != nil {
+		t.Fatalf("RomBanksFromFile: %v", err)
+	}
+	bm, err := NewBankedMemory(banks, 2, false) // soft switches start right after the 2-byte window
+	if err != nil {
+		t.Fatalf("NewBankedMemory: %v", err)
+	}
+
+	if got := bm.Read(1); got != 0x01 {
+		t.Errorf("bank 0 Read(1) = $%02X, want $01", got)
+	}
+
+	bm.Write(3, 0) // soft switch at offset 1 (address 3) selects bank 1
+	if got := bm.Read(1); got != 0x11 {
+		t.Errorf("bank 1 Read(1) = $%02X, want $11", got)
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	go6502 "github.com/rjw57/go6502"
+)
+
+// ExecDebugCmd runs one command from Options.DebugCmds against tm. It
+// recognizes:
+//
+//	watch <addr>[..<addr>] [r|w|rw]   (default rw) - register a watchpoint
+//	unwatch <addr>[..<addr>]          - remove a previously registered watchpoint
+//	trace dump <path>                 - write tm's trace ring buffer to path
+//	coverage dump <path>              - write tm's coverage bitmaps to path
+//
+// trace dump and coverage dump correspond directly to
+// go6502.TracingMemory.DumpTrace and DumpCoverage; watch and unwatch to its
+// Watch and Unwatch.
+func ExecDebugCmd(tm *go6502.TracingMemory, cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	switch fields[0] {
+	case "watch":
+		if len(fields) < 2 {
+			return fmt.Errorf("cli: watch needs an address")
+		}
+		lo, hi, err := parseAddrRange(fields[1])
+		if err != nil {
+			return err
+		}
+		mode := "rw"
+		if len(fields) >= 3 {
+			mode = fields[2]
+		}
+		onRead, onWrite, err := watchCallbacks(mode)
+		if err != nil {
+			return err
+		}
+		tm.Watch(lo, hi, onRead, onWrite)
+		return nil
+
+	case "unwatch":
+		if len(fields) < 2 {
+			return fmt.Errorf("cli: unwatch needs an address")
+		}
+		lo, hi, err := parseAddrRange(fields[1])
+		if err != nil {
+			return err
+		}
+		tm.Unwatch(lo, hi)
+		return nil
+
+	case "trace":
+		if len(fields) != 3 || fields[1] != "dump" {
+			return fmt.Errorf(`cli: want "trace dump <path>"`)
+		}
+		tm.DumpTrace(fields[2])
+		return nil
+
+	case "coverage":
+		if len(fields) != 3 || fields[1] != "dump" {
+			return fmt.Errorf(`cli: want "coverage dump <path>"`)
+		}
+		tm.DumpCoverage(fields[2])
+		return nil
+
+	default:
+		return fmt.Errorf("cli: unknown debugger command %q", fields[0])
+	}
+}
+
+// parseAddrRange parses "addr" or "addr..addr" (hex, no "$" prefix).
+func parseAddrRange(s string) (lo, hi uint16, err error) {
+	parts := strings.SplitN(s, "..", 2)
+
+	loVal, err := strconv.ParseUint(parts[0], 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cli: bad address %q: %v", parts[0], err)
+	}
+	lo = uint16(loVal)
+	hi = lo
+
+	if len(parts) == 2 {
+		hiVal, err := strconv.ParseUint(parts[1], 16, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("cli: bad address %q: %v", parts[1], err)
+		}
+		hi = uint16(hiVal)
+	}
+	return lo, hi, nil
+}
+
+// watchCallbacks builds the onRead/onWrite callbacks for a watch command's
+// "r", "w" or "rw" mode, logging matching accesses to stdout.
+func watchCallbacks(mode string) (onRead, onWrite func(addr uint16, value byte), err error) {
+	log := func(rw string) func(addr uint16, value byte) {
+		return func(addr uint16, value byte) {
+			fmt.Printf("watch: %s $%04X = $%02X\n", rw, addr, value)
+		}
+	}
+
+	switch mode {
+	case "r":
+		return log("R"), nil, nil
+	case "w":
+		return nil, log("W"), nil
+	case "rw":
+		return log("R"), log("W"), nil
+	default:
+		return nil, nil, fmt.Errorf("cli: bad watch mode %q (want r, w or rw)", mode)
+	}
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"testing"
+
+	go6502 "github.com/rjw57/go6502"
+)
+
+func TestExecDebugCmdWatchUnwatch(t *testing.T) {
+	tm := go6502.NewTracingMemory(&go6502.Ram{}, 0)
+
+	if err := ExecDebugCmd(tm, "watch 0010..0020 w"); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if err := ExecDebugCmd(tm, "unwatch 0010..0020"); err != nil {
+		t.Fatalf("unwatch: %v", err)
+	}
+}
+
+func TestExecDebugCmdTraceDump(t *testing.T) {
+	tm := go6502.NewTracingMemory(&go6502.Ram{}, 0)
+	tm.Write(0x0001, 0x01)
+
+	path := t.TempDir() + "/trace.txt"
+	if err := ExecDebugCmd(tm, "trace dump "+path); err != nil {
+		t.Fatalf("trace dump: %v", err)
+	}
+}
+
+func TestExecDebugCmdCoverageDump(t *testing.T) {
+	tm := go6502.NewTracingMemory(&go6502.Ram{}, 0)
+	tm.EnableCoverage()
+	tm.Write(0x0001, 0x01)
+
+	path := t.TempDir() + "/coverage.bin"
+	if err := ExecDebugCmd(tm, "coverage dump "+path); err != nil {
+		t.Fatalf("coverage dump: %v", err)
+	}
+}
+
+func TestExecDebugCmdUnknown(t *testing.T) {
+	tm := go6502.NewTracingMemory(&go6502.Ram{}, 0)
+	if err := ExecDebugCmd(tm, "bogus"); err == nil {
+		t.Fatalf("expected error for unknown command, got nil")
+	}
+}
+
+func TestExecDebugCmdEmpty(t *testing.T) {
+	tm := go6502.NewTracingMemory(&go6502.Ram{}, 0)
+	if err := ExecDebugCmd(tm, ""); err != nil {
+		t.Fatalf("empty command: %v", err)
+	}
+}
@@ -8,17 +8,33 @@ package cli
 import (
 	"flag"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+
+	go6502 "github.com/rjw57/go6502"
+	"github.com/rjw57/go6502/via"
 )
 
 // Options stores the value of command line options after they're parsed.
 type Options struct {
-	ViaDumpBinary bool
-	ViaDumpAscii  bool
-	ViaSsd1306    bool
-	Debug         bool
-	DebugCmds     commandList
-	Speedometer   bool
+	// ViaDumpBinary, ViaDumpAscii and ViaSsd1306 predate -map and are not
+	// appended to Map: this build has no addressable VIA6522 Memory device
+	// for -map to build them into (buildMapDevice rejects "via" entries), so
+	// there is nothing in the map for them to configure. They remain
+	// separate, inert flags until such a device exists.
+	ViaDumpBinary   bool
+	ViaDumpAscii    bool
+	ViaSsd1306      bool
+	ViaSerial       string // "", "ascii" or "rtu"
+	ViaSerialListen string // e.g. "tcp:2502"
+	Debug           bool
+	DebugCmds       commandList
+	Speedometer     bool
+
+	// Map holds the parsed -map entries describing the host's address
+	// space; pass it to BuildMemoryMap to construct the actual devices.
+	Map []MapEntry
 }
 
 // ParseFlags uses the flag stdlib package to parse CLI options.
@@ -27,20 +43,259 @@ func ParseFlags() *Options {
 
 	// Debug
 	flag.BoolVar(&opt.Debug, "debug", false, "Run debugger")
-	flag.Var(&opt.DebugCmds, "debug-commands", "Debugger commands to run, semicolon separated.")
+	flag.Var(&opt.DebugCmds, "debug-commands",
+		"Debugger commands to run, semicolon separated. Recognizes watch "+
+			"<addr>[..<addr>] [r|w|rw], unwatch <addr>[..<addr>], trace dump "+
+			"<path> and coverage dump <path>; see ExecDebugCmd.")
 
 	// VIA
 	flag.BoolVar(&opt.ViaDumpBinary, "via-dump-binary", false, "VIA6522 dumps binary output")
 	flag.BoolVar(&opt.ViaDumpAscii, "via-dump-ascii", false, "VIA6522 dumps ASCII output")
 	flag.BoolVar(&opt.ViaSsd1306, "via-ssd1306", false, "SSD1306 OLED display on VIA6522 port B")
+	flag.StringVar(&opt.ViaSerial, "via-serial", "", "Framed serial channel on VIA6522 port B: ascii or rtu")
+	flag.StringVar(&opt.ViaSerialListen, "via-serial-listen", "",
+		"Bridge the -via-serial channel to a TCP socket, e.g. tcp:2502")
 
 	// Speedometer
 	flag.BoolVar(&opt.Speedometer, "speedometer", false, "Measure effective clock speed")
 
+	// Memory map
+	flag.Var((*mapEntryList)(&opt.Map), "map",
+		"Memory map entries, comma separated, each base:kind[:args...] "+
+			"(e.g. 0000:ram:32k,8000:rom:roms/kernal.bin). Known limitation: "+
+			"only ram, rom and banked-rom kinds can be built this way; via "+
+			"and sd entries are rejected, so a layout needing either of "+
+			"those still requires compiling them in by hand.")
+
 	flag.Parse()
+
 	return opt
 }
 
+// BuildViaSerial constructs the via.ViaSerial described by opt.ViaSerial and
+// opt.ViaSerialListen, or returns a nil ViaSerial if -via-serial wasn't set.
+// If -via-serial-listen was given, its TCP bridge is started before this
+// returns.
+func (opt *Options) BuildViaSerial() (*via.ViaSerial, error) {
+	if opt.ViaSerial == "" {
+		return nil, nil
+	}
+
+	var format via.Format
+	switch opt.ViaSerial {
+	case "ascii":
+		format = via.FormatASCII
+	case "rtu":
+		format = via.FormatRTU
+	default:
+		return nil, fmt.Errorf("cli: unknown -via-serial format %q (want ascii or rtu)", opt.ViaSerial)
+	}
+
+	vs := via.NewViaSerial(format)
+
+	if opt.ViaSerialListen != "" {
+		if err := via.Bridge(opt.ViaSerialListen, vs.Conn()); err != nil {
+			return nil, fmt.Errorf("cli: -via-serial-listen: %v", err)
+		}
+	}
+
+	return vs, nil
+}
+
+// MapEntry is a single parsed entry from a -map flag: the base address it is
+// mounted at, the kind of device to build there, and the kind-specific
+// arguments that follow.
+type MapEntry struct {
+	Base uint16
+	Kind string
+	Args []string
+}
+
+// ParseArg splits a single argument of the form "value@key=val,key=val,..."
+// into its bare value and its parameter map. Arguments with no "@" suffix
+// return an empty, non-nil map.
+func ParseArg(arg string) (value string, params map[string]string) {
+	params = map[string]string{}
+	value = arg
+	if i := strings.IndexByte(arg, '@'); i >= 0 {
+		value, arg = arg[:i], arg[i+1:]
+		for _, kv := range strings.Split(arg, ",") {
+			if kv == "" {
+				continue
+			}
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				params[parts[0]] = parts[1]
+			} else {
+				params[parts[0]] = ""
+			}
+		}
+	}
+	return
+}
+
+// BuildMemoryMap constructs the actual devices described by a parsed -map
+// flag (see Options.Map) and assembles them into a go6502.MemoryMap.
+func BuildMemoryMap(entries []MapEntry) (*go6502.MemoryMap, error) {
+	mems := make(map[uint16]go6502.Memory, len(entries))
+	for _, e := range entries {
+		if _, dup := mems[e.Base]; dup {
+			return nil, fmt.Errorf("cli: -map entry at $%04X (%s): duplicate base address", e.Base, e.Kind)
+		}
+		mem, err := buildMapDevice(e)
+		if err != nil {
+			return nil, fmt.Errorf("cli: -map entry at $%04X (%s): %v", e.Base, e.Kind, err)
+		}
+		mems[e.Base] = mem
+	}
+	return go6502.NewMemoryMap(mems)
+}
+
+// buildMapDevice constructs the Memory for one -map entry. Kinds this build
+// can't construct a real device for (e.g. "via", which would need a VIA6522
+// implementation this tree doesn't have) return a clear error rather than
+// being silently accepted and ignored.
+func buildMapDevice(e MapEntry) (go6502.Memory, error) {
+	switch e.Kind {
+	case "ram":
+		return buildRam(e.Args)
+	case "rom":
+		return buildRom(e.Args)
+	case "banked-rom":
+		return buildBankedRom(e)
+	default:
+		return nil, fmt.Errorf("unsupported kind %q (this build can only construct ram, rom and banked-rom from -map)", e.Kind)
+	}
+}
+
+// buildRam builds a "base:ram:size" entry. go6502.Ram is a fixed 32 KiB
+// array, so the only size this build can satisfy is "32k".
+func buildRam(args []string) (go6502.Memory, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("ram wants exactly one size argument, e.g. ram:32k")
+	}
+	size, err := parseSize(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if size != 0x8000 {
+		return nil, fmt.Errorf("ram size %d not supported; go6502.Ram is fixed at 32k (0x8000)", size)
+	}
+	return &go6502.Ram{}, nil
+}
+
+// buildRom builds a "base:rom:path" entry.
+func buildRom(args []string) (go6502.Memory, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("rom wants exactly one file path argument, e.g. rom:roms/kernal.bin")
+	}
+	return go6502.RomFromFile(args[0])
+}
+
+// buildBankedRom builds a "base:banked-rom:path@banksize=N,switch=addr"
+// entry.
+func buildBankedRom(e MapEntry) (go6502.Memory, error) {
+	if len(e.Args) != 1 {
+		return nil, fmt.Errorf("banked-rom wants exactly one path@banksize=N,switch=addr argument")
+	}
+
+	path, params := ParseArg(e.Args[0])
+
+	bankSizeStr, ok := params["banksize"]
+	if !ok {
+		return nil, fmt.Errorf("banked-rom requires a banksize parameter")
+	}
+	bankSize, err := parseSize(bankSizeStr)
+	if err != nil {
+		return nil, fmt.Errorf("banked-rom banksize: %v", err)
+	}
+
+	switchStr, ok := params["switch"]
+	if !ok {
+		return nil, fmt.Errorf("banked-rom requires a switch parameter")
+	}
+	switchAddr, err := strconv.ParseUint(switchStr, 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("banked-rom switch address: %v", err)
+	}
+
+	banks, err := go6502.RomBanksFromFile(path, bankSize)
+	if err != nil {
+		return nil, err
+	}
+	return go6502.NewBankedMemory(banks, int(uint16(switchAddr))-int(e.Base), false)
+}
+
+// parseSize parses a size such as "32k" or "4096" into a byte count.
+func parseSize(s string) (int, error) {
+	mult := 1
+	if n := len(s); n > 0 && (s[n-1] == 'k' || s[n-1] == 'K') {
+		mult = 1024
+		s = s[:n-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid size %q: must be positive", s)
+	}
+	return n * mult, nil
+}
+
+var entryStartRe = regexp.MustCompile(`^[0-9a-fA-F]{1,4}:`)
+
+// mapEntryList is a flag.Value backing Options.Map. It is set from a single
+// comma-separated -map flag value such as
+// "0000:ram:32k,8000:rom:roms/kernal.bin,5000:sd:disk.img@sclk=0,mosi=1".
+//
+// A kind's own arguments may themselves contain commas (as in the sd example
+// above), so splitting can't simply be "one entry per comma": a part is only
+// the start of a new entry if it begins with a hex base address followed by
+// ":"; any other part is folded into the previous entry's tail.
+type mapEntryList []MapEntry
+
+func (ml *mapEntryList) Set(value string) error {
+	var fields []string
+	for _, part := range strings.Split(value, ",") {
+		if entryStartRe.MatchString(part) || len(fields) == 0 {
+			fields = append(fields, part)
+		} else {
+			fields[len(fields)-1] += "," + part
+		}
+	}
+
+	entries := make([]MapEntry, 0, len(fields))
+	for _, field := range fields {
+		parts := strings.SplitN(field, ":", 3)
+		if len(parts) < 2 {
+			return fmt.Errorf("cli: malformed -map entry %q: want base:kind[:args...]", field)
+		}
+
+		base, err := strconv.ParseUint(parts[0], 16, 16)
+		if err != nil {
+			return fmt.Errorf("cli: malformed -map entry %q: %v", field, err)
+		}
+
+		entry := MapEntry{Base: uint16(base), Kind: parts[1]}
+		if len(parts) == 3 {
+			entry.Args = strings.Split(parts[2], ":")
+		}
+		entries = append(entries, entry)
+	}
+
+	*ml = entries
+	return nil
+}
+
+func (ml *mapEntryList) String() string {
+	parts := make([]string, len(*ml))
+	for i, e := range *ml {
+		parts[i] = fmt.Sprintf("%04x:%s:%s", e.Base, e.Kind, strings.Join(e.Args, ":"))
+	}
+	return strings.Join(parts, ",")
+}
+
 type commandList []string
 
 func (cl *commandList) Set(value string) error {
@@ -0,0 +1,13 @@
+package cli
+
+import "testing"
+
+func TestBuildMemoryMapDuplicateBase(t *testing.T) {
+	entries := []MapEntry{
+		{Base: 0x0000, Kind: "ram", Args: []string{"32k"}},
+		{Base: 0x0000, Kind: "ram", Args: []string{"32k"}},
+	}
+	if _, err := BuildMemoryMap(entries); err == nil {
+		t.Fatalf("expected duplicate base error, got nil")
+	}
+}
@@ -0,0 +1,21 @@
+package cli
+
+import "testing"
+
+func TestParseSizeRejectsNonPositive(t *testing.T) {
+	for _, s := range []string{"0", "-1", "-4k"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize(%q) = nil error, want one", s)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	got, err := parseSize("32k")
+	if err != nil {
+		t.Fatalf("parseSize: %v", err)
+	}
+	if got != 0x8000 {
+		t.Errorf("parseSize(32k) = %d, want %d", got, 0x8000)
+	}
+}
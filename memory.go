@@ -7,6 +7,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"strings"
 )
 
 // Memory is a general interface for reading and writing bytes to and from
@@ -120,3 +122,239 @@ func (om OffsetMemory) String() string {
 func (om OffsetMemory) Write(a uint16, value byte) {
 	om.Memory.Write(a-om.Offset, value)
 }
+
+// mapRegion is one child Memory mounted into a MemoryMap, already wrapped in
+// an OffsetMemory so its Read/Write take addresses in the parent's space.
+type mapRegion struct {
+	base uint16
+	size int
+	mem  Memory
+}
+
+// MemoryMap is a Memory made up of a set of non-overlapping, gapless child
+// regions, each mounted at its own base address. It lets host wiring be
+// described declaratively (e.g. from parsed -map flag entries) instead of
+// hard-coded, while still presenting a single Memory to the CPU core.
+type MemoryMap struct {
+	regions []mapRegion
+}
+
+// NewMemoryMap builds a MemoryMap from a set of child Memory objects keyed by
+// their base address. Each child is mounted at its base address up to
+// base+child.Size(); the regions must exactly tile the address space they
+// cover, with no overlaps and no gaps between consecutive regions, or an
+// error is returned.
+func NewMemoryMap(mems map[uint16]Memory) (*MemoryMap, error) {
+	bases := make([]uint16, 0, len(mems))
+	for base := range mems {
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+
+	mm := &MemoryMap{regions: make([]mapRegion, 0, len(bases))}
+	prevEnd := -1
+	for _, base := range bases {
+		mem := mems[base]
+		size := mem.Size()
+		start, end := int(base), int(base)+size
+
+		if end > 0x10000 {
+			return nil, fmt.Errorf(
+				"go6502: region at $%04X (size %d) extends past the top of the address space", base, size)
+		}
+		if prevEnd >= 0 && start < prevEnd {
+			return nil, fmt.Errorf(
+				"go6502: region at $%04X overlaps the region ending at $%04X", base, prevEnd)
+		}
+		if prevEnd >= 0 && start > prevEnd {
+			return nil, fmt.Errorf(
+				"go6502: gap in memory map between $%04X and $%04X", prevEnd, base)
+		}
+
+		mm.regions = append(mm.regions, mapRegion{
+			base: base,
+			size: size,
+			mem:  OffsetMemory{Offset: base, Memory: mem},
+		})
+		prevEnd = end
+	}
+	return mm, nil
+}
+
+// regionFor returns the region covering address a, panicking if none does
+// (which NewMemoryMap's gap check should have already ruled out for any
+// address within the map's bounds).
+func (mm *MemoryMap) regionFor(a uint16) Memory {
+	for _, r := range mm.regions {
+		if int(a) >= int(r.base) && int(a) < int(r.base)+r.size {
+			return r.mem
+		}
+	}
+	panic(fmt.Sprintf("go6502: no memory mapped at $%04X", a))
+}
+
+// Read returns a byte from whichever region covers the given address.
+func (mm *MemoryMap) Read(a uint16) byte {
+	return mm.regionFor(a).Read(a)
+}
+
+// Write stores a byte in whichever region covers the given address.
+func (mm *MemoryMap) Write(a uint16, value byte) {
+	mm.regionFor(a).Write(a, value)
+}
+
+// Size is the address just past the last mapped region.
+func (mm *MemoryMap) Size() int {
+	if len(mm.regions) == 0 {
+		return 0
+	}
+	last := mm.regions[len(mm.regions)-1]
+	return int(last.base) + last.size
+}
+
+// Shutdown shuts down every region in the map.
+func (mm *MemoryMap) Shutdown() {
+	for _, r := range mm.regions {
+		r.mem.Shutdown()
+	}
+}
+
+func (mm *MemoryMap) String() string {
+	parts := make([]string, len(mm.regions))
+	for i, r := range mm.regions {
+		parts[i] = fmt.Sprintf("$%04X:%v", r.base, r.mem)
+	}
+	return fmt.Sprintf("MemoryMap(%s)", strings.Join(parts, ", "))
+}
+
+// BankedMemory wraps a slice of equally-sized Memory banks behind a single
+// window, with one bank active at a time. A small run of soft-switch
+// addresses, starting switchOffset bytes past this device's own base, selects
+// the active bank: accessing switch address base+switchOffset+N (by read or
+// by write, depending on switchOnRead) makes bank N active. This lets a window much smaller than a
+// large ROM or RAM-disk image address the whole thing a bank at a time,
+// without the CPU core or the Memory interface knowing anything changed.
+type BankedMemory struct {
+	banks        []Memory
+	active       int
+	windowSize   int
+	switchOffset int
+	switchOnRead bool
+	writeProtect bool
+}
+
+// NewBankedMemory builds a BankedMemory over the given banks, which must all
+// be the same size. switchOffset is the offset, relative to wherever this
+// device is itself mounted, of the first soft-switch address; switchOnRead
+// selects whether reading or writing a soft-switch address changes the
+// active bank. Bank 0 is active initially.
+func NewBankedMemory(banks []Memory, switchOffset int, switchOnRead bool) (*BankedMemory, error) {
+	if len(banks) == 0 {
+		return nil, fmt.Errorf("go6502: BankedMemory needs at least one bank")
+	}
+
+	windowSize := banks[0].Size()
+	for i, b := range banks {
+		if b.Size() != windowSize {
+			return nil, fmt.Errorf(
+				"go6502: BankedMemory bank %d has size %d, want %d to match bank 0", i, b.Size(), windowSize)
+		}
+	}
+
+	return &BankedMemory{banks: banks, windowSize: windowSize, switchOffset: switchOffset, switchOnRead: switchOnRead}, nil
+}
+
+// RomBanksFromFile slices a file into fixed-size Rom banks suitable for
+// NewBankedMemory, e.g. to expose a large ROM image through a small window.
+func RomBanksFromFile(path string, bankSize int) ([]Memory, error) {
+	if bankSize <= 0 {
+		return nil, fmt.Errorf("go6502: bank size %d must be positive", bankSize)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%bankSize != 0 {
+		return nil, fmt.Errorf(
+			"go6502: %s is %d bytes, not a multiple of bank size %d", path, len(data), bankSize)
+	}
+
+	banks := make([]Memory, len(data)/bankSize)
+	for i := range banks {
+		banks[i] = &Rom{
+			name: fmt.Sprintf("%s[bank %d]", path, i),
+			size: bankSize,
+			data: data[i*bankSize : (i+1)*bankSize],
+		}
+	}
+	return banks, nil
+}
+
+// WriteProtect flips whether writes to the active bank's window are
+// accepted, letting a RAM-backed BankedMemory double as a write-protected
+// RAM disk.
+func (bm *BankedMemory) WriteProtect(protect bool) {
+	bm.writeProtect = protect
+}
+
+// switchIndex reports the bank index selected by address a, if a is one of
+// the soft-switch addresses.
+func (bm *BankedMemory) switchIndex(a uint16) (int, bool) {
+	idx := int(a) - bm.switchOffset
+	if idx >= 0 && idx < len(bm.banks) {
+		return idx, true
+	}
+	return 0, false
+}
+
+// Shutdown shuts down every bank.
+func (bm *BankedMemory) Shutdown() {
+	for _, b := range bm.banks {
+		b.Shutdown()
+	}
+}
+
+// Read returns a byte from the active bank's window, or triggers a bank
+// switch and reads as open bus (0xFF) if a is a read-triggered soft switch.
+func (bm *BankedMemory) Read(a uint16) byte {
+	if idx, ok := bm.switchIndex(a); ok {
+		if bm.switchOnRead {
+			bm.active = idx
+		}
+		return 0xFF
+	}
+	if int(a) < bm.windowSize {
+		return bm.banks[bm.active].Read(a)
+	}
+	return 0xFF
+}
+
+// Write stores a byte in the active bank's window (unless write-protected),
+// or triggers a bank switch if a is a write-triggered soft switch.
+func (bm *BankedMemory) Write(a uint16, value byte) {
+	if idx, ok := bm.switchIndex(a); ok {
+		if !bm.switchOnRead {
+			bm.active = idx
+		}
+		return
+	}
+	if int(a) < bm.windowSize && !bm.writeProtect {
+		bm.banks[bm.active].Write(a, value)
+	}
+}
+
+// Size spans from this device's base to the end of its window or its last
+// soft-switch address, whichever is further.
+func (bm *BankedMemory) Size() int {
+	size := bm.windowSize
+	if end := bm.switchOffset + len(bm.banks); end > size {
+		size = end
+	}
+	return size
+}
+
+func (bm *BankedMemory) String() string {
+	return fmt.Sprintf("BankedMemory(%d banks of %dK, active=%d)",
+		len(bm.banks), bm.windowSize/1024, bm.active)
+}
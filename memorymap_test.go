@@ -0,0 +1,66 @@
+package go6502
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeTempRom(t *testing.T, data []byte) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "go6502-rom")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return f.Name()
+}
+
+func TestNewMemoryMapOverlap(t *testing.T) {
+	if _, err := NewMemoryMap(map[uint16]Memory{
+		0x0000: &Ram{},
+		0x4000: &Ram{},
+	}); err == nil {
+		t.Fatalf("expected overlap error, got nil")
+	}
+}
+
+func TestNewMemoryMapGap(t *testing.T) {
+	rom, err := RomFromFile(writeTempRom(t, make([]byte, 0x100)))
+	if err != nil {
+		t.Fatalf("RomFromFile: %v", err)
+	}
+	if _, err := NewMemoryMap(map[uint16]Memory{
+		0x0000: &Ram{}, // ends at 0x8000
+		0x9000: rom,    // leaves a gap 0x8000..0x9000
+	}); err == nil {
+		t.Fatalf("expected gap error, got nil")
+	}
+}
+
+func TestMemoryMapReadWrite(t *testing.T) {
+	ram := &Ram{}
+	rom, err := RomFromFile(writeTempRom(t, []byte{0xAA, 0xBB}))
+	if err != nil {
+		t.Fatalf("RomFromFile: %v", err)
+	}
+	mm, err := NewMemoryMap(map[uint16]Memory{
+		0x0000: ram,
+		0x8000: rom,
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryMap: %v", err)
+	}
+
+	mm.Write(0x0010, 0x42)
+	if got := mm.Read(0x0010); got != 0x42 {
+		t.Errorf("Read($0010) = $%02X, want $42", got)
+	}
+	if got := mm.Read(0x8001); got != 0xBB {
+		t.Errorf("Read($8001) = $%02X, want $BB", got)
+	}
+}
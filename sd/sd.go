@@ -4,6 +4,7 @@
 package sd
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 )
@@ -17,6 +18,17 @@ type spiState struct {
 	mosiBuffer byte   // the byte being built from bits
 }
 
+// mode tracks where handleMosiByte is within the SD SPI-mode protocol: either
+// accumulating a 6-byte command, waiting for a write-block data token, or
+// collecting the write-block payload that follows that token.
+type mode int
+
+const (
+	modeCommand mode = iota
+	modeWriteToken
+	modeWriteData
+)
+
 type SdCard struct {
 	data []byte
 	size int
@@ -27,6 +39,14 @@ type SdCard struct {
 	maskMosi uint8
 	maskMiso uint8
 	maskSs   uint8
+
+	cardMode mode
+	cmdBuf   []byte // bytes of the in-progress 6-byte command
+	writeBuf []byte // bytes of the in-progress write-block payload
+
+	idle          bool // GO_IDLE_STATE/SD_SEND_OP_COND idle flag
+	appCmdPending bool // CMD55 seen; next command may be interpreted as an ACMD
+	writeAddr     int  // byte offset of the block targeted by CMD24
 }
 
 // PinMap associates SD card lines with parallel port pin numbers (0..7).
@@ -43,7 +63,7 @@ func (p PinMap) PinMask() byte {
 
 // SdFromFile creates a new SdCard based on the contents of a file.
 func NewSdCard(pm PinMap) (sd *SdCard, err error) {
-	sd = &SdCard{PinMap: pm}
+	sd = &SdCard{PinMap: pm, idle: true}
 
 	sd.maskSclk = 1 << pm.Sclk
 	sd.maskMosi = 1 << pm.Mosi
@@ -52,6 +72,7 @@ func NewSdCard(pm PinMap) (sd *SdCard, err error) {
 
 	sd.spiState.index = 7
 	sd.spiState.misoQueue = make([]byte, 0, 1024)
+	sd.cmdBuf = make([]byte, 0, 6)
 
 	// two busy bytes, then ready.
 	sd.queueMiso(0x00, 0x00, 0x00, 0xFF)
@@ -131,22 +152,185 @@ func (sd *SdCard) handleMisoByte() byte {
 		sd.misoBuffer = sd.misoQueue[0]
 		sd.misoQueue = sd.misoQueue[1:len(sd.misoQueue)]
 	} else {
-		sd.misoBuffer = 0x00 // default to low for empty buffer.
+		sd.misoBuffer = 0xFF // idle line reads high between responses.
 	}
 	return sd.misoBuffer
 }
 
+// handleMosiByte feeds one received byte through the SPI-mode protocol state
+// machine, returning the byte as received (the caller logs it as-is).
 func (sd *SdCard) handleMosiByte() byte {
 	data := sd.mosiBuffer
 	sd.mosiBuffer = 0x00
-	switch data {
-	case 0x40:
-		fmt.Printf("SD: Got 0x40; queueing response bytes.\n")
-		sd.queueMiso(0xAA, 0xAB, 0xAC, 0xAD)
+
+	switch sd.cardMode {
+	case modeWriteToken:
+		// the host clocks out busy/idle filler bytes until it sends the
+		// data token that starts the block it wants written.
+		if data == 0xFE {
+			sd.writeBuf = sd.writeBuf[:0]
+			sd.cardMode = modeWriteData
+		}
+	case modeWriteData:
+		sd.writeBuf = append(sd.writeBuf, data)
+		if len(sd.writeBuf) == 512+2 { // payload plus its CRC16
+			sd.completeWriteBlock()
+			sd.cardMode = modeCommand
+		}
+	default:
+		sd.handleCommandByte(data)
 	}
+
 	return data
 }
 
+// handleCommandByte accumulates bytes of a 6-byte SD command (start bits
+// "01", 6-bit index, 4-byte argument, 7-bit CRC and a stop bit), discarding
+// stray bytes until one with a valid start-bit pattern begins a command.
+func (sd *SdCard) handleCommandByte(data byte) {
+	if len(sd.cmdBuf) == 0 && data&0xC0 != 0x40 {
+		return
+	}
+
+	sd.cmdBuf = append(sd.cmdBuf, data)
+	if len(sd.cmdBuf) < 6 {
+		return
+	}
+
+	cmd := append([]byte(nil), sd.cmdBuf...)
+	sd.cmdBuf = sd.cmdBuf[:0]
+	sd.handleCommand(cmd)
+}
+
+// handleCommand dispatches a fully-received 6-byte command, queueing the
+// appropriate R1/R3/R7 response (and any following data block) on the MISO
+// queue.
+func (sd *SdCard) handleCommand(cmd []byte) {
+	index := cmd[0] & 0x3F
+	arg := binary.BigEndian.Uint32(cmd[1:5])
+	isAppCmd := sd.appCmdPending
+	sd.appCmdPending = false
+
+	switch {
+	case index == 0: // CMD0: GO_IDLE_STATE
+		if cmd[5] != crc7(cmd[:5]) {
+			sd.queueMiso(sd.r1() | 0x08) // COM CRC error
+			return
+		}
+		sd.idle = true
+		sd.queueMiso(sd.r1())
+
+	case index == 8: // CMD8: SEND_IF_COND
+		if cmd[5] != crc7(cmd[:5]) {
+			sd.queueMiso(sd.r1() | 0x08)
+			return
+		}
+		sd.queueMiso(sd.r1(), cmd[1], cmd[2], cmd[3], cmd[4]) // echo voltage/check pattern
+
+	case index == 55: // CMD55: APP_CMD
+		sd.appCmdPending = true
+		sd.queueMiso(sd.r1())
+
+	case isAppCmd && index == 41: // ACMD41: SD_SEND_OP_COND
+		sd.idle = false
+		sd.queueMiso(sd.r1())
+
+	case index == 58: // CMD58: READ_OCR
+		ocr := uint32(0xC0FF8000) // power up done, CCS=1 (SDHC/SDXC)
+		sd.queueMiso(sd.r1(), byte(ocr>>24), byte(ocr>>16), byte(ocr>>8), byte(ocr))
+
+	case index == 17: // CMD17: READ_SINGLE_BLOCK
+		sd.queueReadBlock(arg)
+
+	case index == 24: // CMD24: WRITE_BLOCK
+		sd.writeAddr = int(arg) * 512
+		sd.queueMiso(sd.r1())
+		sd.cardMode = modeWriteToken
+
+	default:
+		sd.queueMiso(sd.r1())
+	}
+}
+
+// r1 builds the R1 response byte; every other response is R1 with extra
+// bytes appended, so every case above starts from this.
+func (sd *SdCard) r1() byte {
+	if sd.idle {
+		return 0x01
+	}
+	return 0x00
+}
+
+// queueReadBlock answers CMD17 by queueing R1, a handful of busy bytes, the
+// 0xFE data token, the 512-byte block at the given (SDHC) block address, and
+// its CRC16.
+func (sd *SdCard) queueReadBlock(blockAddr uint32) {
+	sd.queueMiso(sd.r1())
+	sd.queueMiso(0xFF, 0xFF) // busy while the "card" fetches the block
+	sd.queueMiso(0xFE)       // start-of-data token
+
+	offset := int(blockAddr) * 512
+	block := make([]byte, 512)
+	if offset >= 0 && offset+len(block) <= len(sd.data) {
+		copy(block, sd.data[offset:offset+len(block)])
+	}
+	sd.queueMiso(block...)
+
+	crc := crc16CCITT(block)
+	sd.queueMiso(byte(crc>>8), byte(crc))
+}
+
+// completeWriteBlock answers the data block that followed CMD24 by storing
+// it (CRC is accepted unconditionally, matching real cards in this mode) and
+// queueing the data-response token plus a run of busy bytes.
+func (sd *SdCard) completeWriteBlock() {
+	block := sd.writeBuf[:512]
+	if sd.writeAddr >= 0 && sd.writeAddr+len(block) <= len(sd.data) {
+		copy(sd.data[sd.writeAddr:sd.writeAddr+len(block)], block)
+	}
+
+	sd.queueMiso(0xE5) // data response token: data accepted
+	for i := 0; i < 4; i++ {
+		sd.queueMiso(0x00) // busy programming
+	}
+	sd.queueMiso(0xFF) // ready
+}
+
 func (sd *SdCard) queueMiso(bytes ...byte) {
 	sd.misoQueue = append(sd.misoQueue, bytes...)
 }
+
+// crc7 computes the 7-bit CRC (polynomial x^7+x^3+1) used to validate SD
+// commands, returned pre-shifted with the stop bit set so it can be compared
+// directly against the final byte of a received command.
+func crc7(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		d := b
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if (d^crc)&0x80 != 0 {
+				crc ^= 0x09
+			}
+			d <<= 1
+		}
+	}
+	return (crc << 1) | 1
+}
+
+// crc16CCITT computes the CRC16-CCITT (poly 0x1021, init 0x0000) used to
+// checksum SD data blocks.
+func crc16CCITT(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
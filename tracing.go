@@ -0,0 +1,203 @@
+package go6502
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// watchRange is one registered watchpoint: accesses to [lo, hi] invoke
+// onRead/onWrite (either may be nil).
+type watchRange struct {
+	lo, hi          uint16
+	onRead, onWrite func(addr uint16, value byte)
+}
+
+// traceEntry is one recorded access in a TracingMemory's ring buffer.
+type traceEntry struct {
+	addr  uint16
+	value byte
+	write bool
+	cycle uint64
+}
+
+// TracingMemory wraps an inner Memory, recording every access it sees. It is
+// itself a Memory, so it can be layered above any other region (a Rom, a
+// Ram, an OffsetMemory, a MemoryMap, ...) without the CPU core needing to
+// know it's there.
+type TracingMemory struct {
+	Memory
+
+	watches []watchRange // sorted by lo
+	maxHi   []uint16     // maxHi[i] = max(watches[0..i].hi); prunes the scan below
+
+	trace    []traceEntry
+	traceLen int // number of valid entries; caps out at len(trace)
+	traceAt  int // index the next entry is written to
+	cycle    uint64
+
+	readCoverage  []bool
+	writeCoverage []bool
+}
+
+// NewTracingMemory wraps inner, keeping a ring buffer of the last traceCap
+// accesses. Watchpoints and coverage tracking are opt-in: see Watch and
+// EnableCoverage.
+func NewTracingMemory(inner Memory, traceCap int) *TracingMemory {
+	return &TracingMemory{
+		Memory: inner,
+		trace:  make([]traceEntry, traceCap),
+	}
+}
+
+// SetCycle records the CPU cycle count to attach to subsequent accesses,
+// so a dumped trace can be correlated against the rest of a debug session.
+func (tm *TracingMemory) SetCycle(cycle uint64) {
+	tm.cycle = cycle
+}
+
+// Watch registers a watchpoint over [lo, hi], invoking onRead/onWrite (either
+// may be nil) whenever an address in that range is read or written.
+func (tm *TracingMemory) Watch(lo, hi uint16, onRead, onWrite func(addr uint16, value byte)) {
+	tm.watches = append(tm.watches, watchRange{lo: lo, hi: hi, onRead: onRead, onWrite: onWrite})
+	sort.Slice(tm.watches, func(i, j int) bool { return tm.watches[i].lo < tm.watches[j].lo })
+	tm.rebuildMaxHi()
+}
+
+// Unwatch removes any watchpoint previously registered with exactly this
+// [lo, hi] range.
+func (tm *TracingMemory) Unwatch(lo, hi uint16) {
+	kept := tm.watches[:0]
+	for _, w := range tm.watches {
+		if w.lo != lo || w.hi != hi {
+			kept = append(kept, w)
+		}
+	}
+	tm.watches = kept
+	tm.rebuildMaxHi()
+}
+
+func (tm *TracingMemory) rebuildMaxHi() {
+	tm.maxHi = make([]uint16, len(tm.watches))
+	var max uint16
+	for i, w := range tm.watches {
+		if w.hi > max {
+			max = w.hi
+		}
+		tm.maxHi[i] = max
+	}
+}
+
+// fireWatches invokes every registered watchpoint covering addr. watches is
+// sorted by lo with maxHi as a running prefix maximum of hi, so once
+// maxHi[i] < addr no earlier watch (they all have even smaller lo) can cover
+// addr either, and the scan stops.
+func (tm *TracingMemory) fireWatches(addr uint16, value byte, write bool) {
+	idx := sort.Search(len(tm.watches), func(i int) bool { return tm.watches[i].lo > addr }) - 1
+	for i := idx; i >= 0; i-- {
+		if tm.maxHi[i] < addr {
+			break
+		}
+		w := tm.watches[i]
+		if w.lo > addr || w.hi < addr {
+			continue
+		}
+		if write && w.onWrite != nil {
+			w.onWrite(addr, value)
+		} else if !write && w.onRead != nil {
+			w.onRead(addr, value)
+		}
+	}
+}
+
+func (tm *TracingMemory) record(addr uint16, value byte, write bool) {
+	if len(tm.trace) == 0 {
+		return // tracing disabled; only watchpoints and/or coverage wanted
+	}
+	tm.trace[tm.traceAt] = traceEntry{addr: addr, value: value, write: write, cycle: tm.cycle}
+	tm.traceAt = (tm.traceAt + 1) % len(tm.trace)
+	if tm.traceLen < len(tm.trace) {
+		tm.traceLen++
+	}
+}
+
+// EnableCoverage starts tracking which addresses have been read and/or
+// written. Coverage is indexed by the absolute uint16 address passed to
+// Read/Write, not by tm.Memory.Size(): a TracingMemory is commonly layered
+// directly over an OffsetMemory (see the TracingMemory doc), whose Size() is its
+// child's size, not the top of the mounted window, while Read/Write still
+// receive addresses in the full mounted range.
+func (tm *TracingMemory) EnableCoverage() {
+	tm.readCoverage = make([]bool, 0x10000)
+	tm.writeCoverage = make([]bool, 0x10000)
+}
+
+// Read passes through to the inner Memory, then records the access, fires
+// any covering watchpoints, and marks read coverage if enabled.
+func (tm *TracingMemory) Read(a uint16) byte {
+	v := tm.Memory.Read(a)
+	tm.record(a, v, false)
+	tm.fireWatches(a, v, false)
+	if tm.readCoverage != nil {
+		tm.readCoverage[a] = true
+	}
+	return v
+}
+
+// Write passes through to the inner Memory, then records the access, fires
+// any covering watchpoints, and marks write coverage if enabled.
+func (tm *TracingMemory) Write(a uint16, value byte) {
+	tm.Memory.Write(a, value)
+	tm.record(a, value, true)
+	tm.fireWatches(a, value, true)
+	if tm.writeCoverage != nil {
+		tm.writeCoverage[a] = true
+	}
+}
+
+// DumpTrace writes the ring buffer's accesses, oldest first, as one
+// "R/W $addr = $value @cycle" line per access.
+func (tm *TracingMemory) DumpTrace(path string) {
+	if len(tm.trace) == 0 {
+		if err := ioutil.WriteFile(path, nil, 0640); err != nil {
+			panic(err)
+		}
+		return
+	}
+	lines := make([]byte, 0, tm.traceLen*32)
+	start := (tm.traceAt - tm.traceLen + len(tm.trace)) % len(tm.trace)
+	for i := 0; i < tm.traceLen; i++ {
+		e := tm.trace[(start+i)%len(tm.trace)]
+		rw := "R"
+		if e.write {
+			rw = "W"
+		}
+		lines = append(lines, []byte(fmt.Sprintf("%s $%04X = $%02X @%d\n", rw, e.addr, e.value, e.cycle))...)
+	}
+	if err := ioutil.WriteFile(path, lines, 0640); err != nil {
+		panic(err)
+	}
+}
+
+// DumpCoverage writes the read and write coverage bitmaps (one bit per
+// address, 8 addresses per byte, reads followed by writes) to path.
+// EnableCoverage must have been called first.
+func (tm *TracingMemory) DumpCoverage(path string) {
+	if tm.readCoverage == nil {
+		panic("go6502: DumpCoverage called without EnableCoverage")
+	}
+	data := append(packBitmap(tm.readCoverage), packBitmap(tm.writeCoverage)...)
+	if err := ioutil.WriteFile(path, data, 0640); err != nil {
+		panic(err)
+	}
+}
+
+func packBitmap(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, set := range bits {
+		if set {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
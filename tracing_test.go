@@ -0,0 +1,99 @@
+package go6502
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTracingMemoryZeroCapDumpTrace(t *testing.T) {
+	tm := NewTracingMemory(&Ram{}, 0)
+	tm.Read(0x1234)
+	tm.Write(0x1234, 0x42)
+
+	f, err := ioutil.TempFile("", "go6502-trace")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	tm.DumpTrace(f.Name()) // must not panic with a zero-length ring buffer
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("DumpTrace wrote %d bytes for a disabled tracer, want 0", len(data))
+	}
+}
+
+func TestTracingMemoryTraceRoundTrip(t *testing.T) {
+	tm := NewTracingMemory(&Ram{}, 4)
+	tm.Write(0x0001, 0xAA)
+	tm.Read(0x0001)
+
+	f, err := ioutil.TempFile("", "go6502-trace")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	tm.DumpTrace(f.Name())
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(data) == 0 {
+		t.Fatalf("DumpTrace wrote no data for a populated trace")
+	}
+}
+
+func TestTracingMemoryWatch(t *testing.T) {
+	tm := NewTracingMemory(&Ram{}, 0)
+
+	var gotAddr uint16
+	var gotValue byte
+	tm.Watch(0x0010, 0x0020, nil, func(addr uint16, value byte) {
+		gotAddr, gotValue = addr, value
+	})
+
+	tm.Write(0x0015, 0x7F)
+	if gotAddr != 0x0015 || gotValue != 0x7F {
+		t.Errorf("watch fired with (%04X, %02X), want (0015, 7F)", gotAddr, gotValue)
+	}
+
+	tm.Unwatch(0x0010, 0x0020)
+	gotAddr, gotValue = 0, 0
+	tm.Write(0x0015, 0x01)
+	if gotAddr != 0 || gotValue != 0 {
+		t.Errorf("watch fired after Unwatch: (%04X, %02X)", gotAddr, gotValue)
+	}
+}
+
+func TestTracingMemoryCoverage(t *testing.T) {
+	tm := NewTracingMemory(&Ram{}, 0)
+	tm.EnableCoverage()
+	tm.Read(0x0100)
+	tm.Write(0x0200, 0x01)
+
+	f, err := ioutil.TempFile("", "go6502-coverage")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	tm.DumpCoverage(f.Name()) // must not panic once EnableCoverage has been called
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(data) == 0 {
+		t.Fatalf("DumpCoverage wrote no data")
+	}
+}
@@ -0,0 +1,260 @@
+/*
+	Package via provides memory-mapped devices that sit behind a 6522 VIA
+	port, bridging guest 6502 software to host-side Go code.
+*/
+package via
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Format selects the frame encoding a ViaSerial speaks, modeled on the two
+// Modbus serial framings.
+type Format int
+
+const (
+	// FormatASCII frames as ":" + hex(payload+LRC) + "\r\n".
+	FormatASCII Format = iota
+	// FormatRTU frames as payload + CRC16, delimited by an inter-frame gap.
+	FormatRTU
+)
+
+// rtuGapTicks is how many consecutive idle Tick calls count as the RTU
+// 3.5-character inter-frame gap that ends a frame.
+const rtuGapTicks = 4
+
+// ViaSerial is a one-byte memory-mapped register that a guest drives to send
+// and receive whole frames one byte at a time, in either Modbus-ASCII or
+// Modbus-RTU framing. Decoded incoming frames are delivered on a channel;
+// the host side talks to it through the io.ReadWriteCloser returned by Conn.
+type ViaSerial struct {
+	format Format
+
+	rxBuf     []byte // bytes of the frame currently arriving from the guest
+	idleTicks int     // RTU only: Ticks since the last guest write
+	frames    chan []byte
+
+	txMu    sync.Mutex
+	txQueue []byte // bytes of the frame currently being clocked out to the guest
+}
+
+// NewViaSerial creates a ViaSerial speaking the given framing.
+func NewViaSerial(format Format) *ViaSerial {
+	return &ViaSerial{format: format, frames: make(chan []byte, 16)}
+}
+
+// Shutdown is part of the go6502.Memory interface.
+func (vs *ViaSerial) Shutdown() {
+	close(vs.frames)
+}
+
+// Size is part of the go6502.Memory interface: ViaSerial is a single
+// register.
+func (vs *ViaSerial) Size() int {
+	return 1
+}
+
+// Read pops the next byte of the frame currently being sent to the guest, or
+// 0x00 if nothing is queued.
+func (vs *ViaSerial) Read(_ uint16) byte {
+	vs.txMu.Lock()
+	defer vs.txMu.Unlock()
+
+	if len(vs.txQueue) == 0 {
+		return 0x00
+	}
+	b := vs.txQueue[0]
+	vs.txQueue = vs.txQueue[1:]
+	return b
+}
+
+// Write feeds one byte of an incoming frame from the guest.
+func (vs *ViaSerial) Write(_ uint16, value byte) {
+	switch vs.format {
+	case FormatASCII:
+		vs.writeAsciiByte(value)
+	case FormatRTU:
+		vs.rxBuf = append(vs.rxBuf, value)
+		vs.idleTicks = 0
+	}
+}
+
+// Tick marks the passing of one guest clock cycle. It only matters for RTU
+// framing, which has no explicit end-of-frame byte and instead relies on an
+// inter-frame gap to know a frame is complete.
+func (vs *ViaSerial) Tick() {
+	if vs.format != FormatRTU || len(vs.rxBuf) == 0 {
+		return
+	}
+	vs.idleTicks++
+	if vs.idleTicks >= rtuGapTicks {
+		vs.finishRtuFrame()
+	}
+}
+
+func (vs *ViaSerial) writeAsciiByte(b byte) {
+	if b == ':' { // (re)synchronize on a frame start
+		vs.rxBuf = vs.rxBuf[:0]
+		return
+	}
+
+	vs.rxBuf = append(vs.rxBuf, b)
+	if n := len(vs.rxBuf); n >= 2 && vs.rxBuf[n-2] == '\r' && vs.rxBuf[n-1] == '\n' {
+		vs.finishAsciiFrame(vs.rxBuf[:n-2])
+		vs.rxBuf = vs.rxBuf[:0]
+	}
+}
+
+// finishAsciiFrame decodes the hex body of a completed ASCII frame (payload
+// followed by its one-byte LRC) and emits the payload if the LRC matches.
+func (vs *ViaSerial) finishAsciiFrame(body []byte) {
+	raw, err := hex.DecodeString(string(body))
+	if err != nil || len(raw) == 0 {
+		return
+	}
+
+	payload, gotLRC := raw[:len(raw)-1], raw[len(raw)-1]
+	if lrc(payload) != gotLRC {
+		return
+	}
+	vs.emit(payload)
+}
+
+// finishRtuFrame checks the trailing CRC16 of the accumulated RTU bytes and
+// emits the payload if it matches.
+func (vs *ViaSerial) finishRtuFrame() {
+	defer func() {
+		vs.rxBuf = vs.rxBuf[:0]
+		vs.idleTicks = 0
+	}()
+
+	if len(vs.rxBuf) < 3 {
+		return
+	}
+
+	payload := vs.rxBuf[:len(vs.rxBuf)-2]
+	got := uint16(vs.rxBuf[len(vs.rxBuf)-1])<<8 | uint16(vs.rxBuf[len(vs.rxBuf)-2])
+	if crc16Modbus(payload) != got {
+		return
+	}
+	vs.emit(payload)
+}
+
+func (vs *ViaSerial) emit(payload []byte) {
+	cp := append([]byte(nil), payload...)
+	select {
+	case vs.frames <- cp:
+	default: // no reader keeping up; drop rather than stall the guest
+	}
+}
+
+// queueOutgoing encodes payload as a frame and appends it to the bytes the
+// guest will clock in via Read.
+func (vs *ViaSerial) queueOutgoing(payload []byte) {
+	var frame []byte
+	switch vs.format {
+	case FormatASCII:
+		raw := append(append([]byte(nil), payload...), lrc(payload))
+		frame = make([]byte, 0, len(raw)*2+3)
+		frame = append(frame, ':')
+		frame = append(frame, []byte(strings.ToUpper(hex.EncodeToString(raw)))...)
+		frame = append(frame, '\r', '\n')
+	case FormatRTU:
+		crc := crc16Modbus(payload)
+		frame = append(append([]byte(nil), payload...), byte(crc), byte(crc>>8))
+	}
+
+	vs.txMu.Lock()
+	vs.txQueue = append(vs.txQueue, frame...)
+	vs.txMu.Unlock()
+}
+
+// Conn returns the host-facing side of this ViaSerial: writes are encoded
+// and clocked in to the guest, reads return payloads decoded from the guest.
+func (vs *ViaSerial) Conn() io.ReadWriteCloser {
+	return &viaSerialConn{vs: vs}
+}
+
+type viaSerialConn struct {
+	vs *ViaSerial
+}
+
+func (c *viaSerialConn) Read(p []byte) (int, error) {
+	payload, ok := <-c.vs.frames
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(p, payload), nil
+}
+
+func (c *viaSerialConn) Write(p []byte) (int, error) {
+	c.vs.queueOutgoing(p)
+	return len(p), nil
+}
+
+func (c *viaSerialConn) Close() error {
+	c.vs.Shutdown()
+	return nil
+}
+
+// Bridge listens on addr (of the form "tcp:<port>") and splices every
+// accepted connection to conn, so an external tool (including a real Modbus
+// master) can talk to the guest firmware behind conn.
+func Bridge(addr string, conn io.ReadWriteCloser) error {
+	parts := strings.SplitN(addr, ":", 2)
+	if len(parts) != 2 || parts[0] != "tcp" {
+		return fmt.Errorf("via: unsupported listen address %q, want tcp:<port>", addr)
+	}
+
+	ln, err := net.Listen("tcp", ":"+parts[1])
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer ln.Close()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				go io.Copy(c, conn)
+				io.Copy(conn, c)
+			}(c)
+		}
+	}()
+	return nil
+}
+
+// lrc computes the Modbus-ASCII longitudinal redundancy check: the two's
+// complement of the sum of data's bytes.
+func lrc(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
+
+// crc16Modbus computes the Modbus-RTU CRC16 (poly 0xA001, init 0xFFFF).
+func crc16Modbus(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}